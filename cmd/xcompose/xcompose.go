@@ -12,34 +12,47 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	// For Compose.pre.
 	_ "embed"
+
+	"github.com/fsnotify/fsnotify"
 )
 
+// systemComposeDir is libX11's compiled-in system compose directory, used to
+// resolve the %S token in "include" directives.
+const systemComposeDir = "/usr/share/X11/locale"
+
 // File obtained from https://cgit.freedesktop.org/xorg/lib/libX11/plain/nls/en_US.UTF-8/Compose.pre
 //go:embed Compose.pre
 var compose string
 
 func main() {
 	var (
-		dump  = flag.Bool("dump", false, "dump the xcompose config file to output.")
-		out   = flag.String("o", "", "output destination — stdout if empty.")
-		altGr = flag.String("altgr", "§", "rune to bind AltGr to (use Karabiner-Elements).")
-		help  = flag.Bool("help", false, "display help.")
+		dump    = flag.Bool("dump", false, "dump the xcompose config file to output.")
+		out     = flag.String("o", "", "output destination — stdout if empty.")
+		altGr   = flag.String("altgr", "§", "rune to bind AltGr to (use Karabiner-Elements).")
+		compose = flag.String("f", "", "path to an X11 Compose file to use instead of the embedded default.")
+		include = flag.String("include", "", "path to the user's XCompose file, with \"include\" directives resolved and entries overriding the default (default: $XCOMPOSE, falling back to ~/.XCompose if it exists).")
+		watch   = flag.Bool("watch", false, "watch the file given by -f and regenerate -o on every change; requires -f and -o.")
+		help    = flag.Bool("help", false, "display help.")
 	)
 
 	flag.Parse()
 	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), `Usage of %s: 
+		fmt.Fprintf(flag.CommandLine.Output(), `Usage of %s:
 
 xcompose generates a DefaultKeyBindings.dict key binding map from an X11 Compose
 definition file. Using the dict file depends on mapping a sensible modifier key
@@ -48,6 +61,16 @@ key is mapped to '§'.
 
 The generated dictionary is then placed in ~/Library/KeyBindings/DefaultKeyBindings.dict.
 
+With -watch, xcompose stays running and regenerates -o every time the file
+named by -f changes, instead of generating once and exiting.
+
+By default, xcompose also looks for the user's own XCompose file ($XCOMPOSE,
+or ~/.XCompose) and, if found, merges it over the built-in defaults following
+the same include and override rules as libX11. Use -include to point at a
+different file, or an empty XCompose file to disable this. Sequences whose
+keysyms can't be resolved are dropped and reported as JSON diagnostics on
+stderr.
+
 `, os.Args[0])
 		flag.PrintDefaults()
 	}
@@ -56,6 +79,26 @@ The generated dictionary is then placed in ~/Library/KeyBindings/DefaultKeyBindi
 		os.Exit(0)
 	}
 
+	runes := []rune(*altGr)
+	if len(runes) != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	user := map[string]rune{"<Multi_key>": runes[0]}
+
+	if *watch {
+		if *compose == "" || *out == "" {
+			fmt.Fprintln(flag.CommandLine.Output(), "-watch requires both -f and -o to be set")
+			flag.Usage()
+			os.Exit(2)
+		}
+		err := watchAndRegenerate(user, *compose, *include, *out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	w := os.Stdout
 	if *out != "" {
 		var err error
@@ -73,25 +116,214 @@ The generated dictionary is then placed in ~/Library/KeyBindings/DefaultKeyBindi
 		}
 	}
 
-	runes := []rune(*altGr)
-	if len(runes) != 1 {
-		flag.Usage()
-		os.Exit(2)
+	var src io.Reader
+	if *compose != "" {
+		f, err := os.Open(*compose)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		src = f
 	}
-	user := map[string]rune{"<Multi_key>": runes[0]}
 
-	err := format(w, buildDict(user, nil), 0)
+	dict := buildMergedDict(user, src, *include)
+
+	err := format(w, dict, 0)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
+// buildMergedDict builds the key binding dict from src (the embedded default
+// if src is nil), then merges in the XCompose file at includePath — or, if
+// includePath is empty, $XCOMPOSE or ~/.XCompose if one of those exists —
+// so that the XCompose file's entries override the dict built from src.
+// Diagnostics for dropped, unresolved lines from either source are printed
+// to stderr before returning.
+func buildMergedDict(user map[string]rune, src io.Reader, includePath string) map[string]interface{} {
+	var diags []diagnostic
+	dict := buildDict(user, src, recordDiagnostic(&diags))
+	mergeXCompose(dict, user, includePath, recordDiagnostic(&diags))
+	reportDiagnostics(diags)
+	return dict
+}
+
+// mergeXCompose resolves path — or, if path is empty, $XCOMPOSE or
+// ~/.XCompose — loads it as an XCompose file following libX11's include and
+// override semantics, and merges its entries into dict so that they take
+// precedence over whatever dict already contains.
+//
+// When path is empty and no default XCompose file can be found, this is a
+// silent no-op: not having a personal XCompose file is the common case, not
+// an error. When path is given explicitly, a missing file is reported.
+func mergeXCompose(dict map[string]interface{}, user map[string]rune, path string, diag func(source, text, reason string)) {
+	explicit := path != ""
+	if !explicit {
+		path = defaultXComposePath()
+		if path == "" {
+			return
+		}
+		if _, err := os.Stat(path); err != nil {
+			return
+		}
+	}
+	dict2, err := loadXCompose(path, user, diag)
+	if err != nil {
+		if explicit {
+			log.Fatal(err)
+		}
+		return
+	}
+	mergeInto(dict, dict2)
+}
+
+// defaultXComposePath returns the conventional location of the user's
+// XCompose file: $XCOMPOSE if set, otherwise ~/.XCompose.
+func defaultXComposePath() string {
+	if p := os.Getenv("XCOMPOSE"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".XCompose")
+}
+
+// regenerate reads the Compose file at composePath (the embedded default if
+// composePath is empty), merges in the XCompose file at includePath (or the
+// default XCompose location if includePath is empty), builds the key
+// binding dict and writes it to outPath. The write is atomic: the dict is
+// formatted into a temporary file in outPath's directory which is then
+// renamed over outPath, so readers never observe a partially written file.
+func regenerate(user map[string]rune, composePath, includePath, outPath string) error {
+	var src io.Reader
+	if composePath != "" {
+		f, err := os.Open(composePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		src = f
+	}
+	dict := buildMergedDict(user, src, includePath)
+
+	tmp, err := os.CreateTemp(filepath.Dir(outPath), ".xcompose-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op once the rename below succeeds
+
+	err = format(tmp, dict, 0)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpName, outPath)
+}
+
+// watchAndRegenerate regenerates outPath from composePath (and includePath,
+// or the default XCompose file if includePath is empty) once, then again
+// every time either of them changes, until an unrecoverable error occurs.
+//
+// composePath's, includePath's and outPath's parent directories are watched
+// rather than the files themselves: editors commonly save by writing a new
+// file and renaming it over the original, which replaces the inode fsnotify
+// would otherwise be watching and silently stops delivery of further
+// events. Watching the directory instead means the replacement is always
+// seen.
+//
+// Bursts of events for the watched files (for example a series of
+// autosaves) are coalesced: regeneration runs debounce after the last
+// observed event rather than once per event.
+func watchAndRegenerate(user map[string]rune, composePath, includePath, outPath string) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	targets := map[string]bool{filepath.Clean(composePath): true}
+	// Watched under its own name, not passed to regenerate as includePath:
+	// regenerate/mergeXCompose must still see an empty includePath so a
+	// missing default XCompose file stays the documented silent no-op
+	// rather than becoming an explicit, fatal one.
+	watchIncludePath := includePath
+	if watchIncludePath == "" {
+		watchIncludePath = defaultXComposePath()
+	}
+	if watchIncludePath != "" {
+		// Watched even if the file doesn't exist yet, so that creating it
+		// while xcompose is running (the common way a user starts using a
+		// personal XCompose file) triggers a regeneration.
+		targets[filepath.Clean(watchIncludePath)] = true
+	}
+	dirs := map[string]bool{filepath.Dir(outPath): true}
+	for t := range targets {
+		dirs[filepath.Dir(t)] = true
+	}
+	for dir := range dirs {
+		err := w.Add(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	const debounce = 200 * time.Millisecond
+
+	run := func() {
+		err := regenerate(user, composePath, includePath, outPath)
+		if err != nil {
+			log.Printf("failed to regenerate %s: %v", outPath, err)
+			return
+		}
+		log.Printf("regenerated %s from %s", outPath, composePath)
+	}
+	run()
+
+	var timer *time.Timer
+	pending := make(chan struct{}, 1)
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if !targets[filepath.Clean(ev.Name)] {
+				continue
+			}
+			if timer == nil {
+				timer = time.AfterFunc(debounce, func() { pending <- struct{}{} })
+			} else {
+				timer.Reset(debounce)
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("watch error: %v", err)
+		case <-pending:
+			run()
+		}
+	}
+}
+
 func dumpCompose(w io.Writer) error {
 	_, err := io.Copy(w, strings.NewReader(compose))
 	return err
 }
 
-func buildDict(user map[string]rune, src io.Reader) map[string]interface{} {
+// buildDict parses a Compose definition stream (the embedded default if src
+// is nil) into a key binding dict. Only lines starting with "<" are parsed;
+// every other line, including an entry whose first keysym carries a
+// modifier prefix such as "Ctrl<a>", is skipped without a diagnostic.
+// Parsed lines whose keysyms can't be resolved — including those with a
+// modifier prefix on a later keysym, see keyFor — are dropped and reported
+// through diag, which may be nil to discard them silently.
+func buildDict(user map[string]rune, src io.Reader, diag func(source, text, reason string)) map[string]interface{} {
 	mapping := make(map[string]interface{})
 	if src == nil {
 		src = strings.NewReader(compose)
@@ -101,42 +333,142 @@ func buildDict(user map[string]rune, src io.Reader) map[string]interface{} {
 		if !bytes.HasPrefix(sc.Bytes(), []byte{'<'}) {
 			continue
 		}
-		parts := strings.FieldsFunc(sc.Text(), func(r rune) bool {
-			return r == ':'
-		})
-		if len(parts) < 2 {
-			log.Fatalf("unexpected number of parts: %s", sc.Text())
-		}
-		path := strings.Fields(parts[0])
-		for i, p := range path {
-			k, err := keyFor(p, user)
-			if err != nil {
+		path, val, err := parseEntry(sc.Text(), user)
+		if err != nil {
+			var uerr *unresolvedKeysymError
+			if errors.As(err, &uerr) {
+				if diag != nil {
+					diag("", sc.Text(), err.Error())
+				}
 				continue
 			}
-			path[i] = k
+			log.Fatalf("%s: %s", err, sc.Text())
 		}
-		val, err := strconv.Unquote(strings.FieldsFunc(parts[1], func(r rune) bool {
-			return r == ' ' || r == '\t'
-		})[0])
+		insert(mapping, val, path...)
+	}
+	return mapping
+}
+
+// unresolvedKeysymError reports that one or more keysyms on a Compose line
+// could not be resolved to a literal character. It is distinguished from
+// other parse errors so that callers can drop the offending line and
+// continue instead of aborting.
+type unresolvedKeysymError struct {
+	detail string
+}
+
+func (e *unresolvedKeysymError) Error() string { return e.detail }
+
+// parseEntry parses a single Compose definition line of the form
+// `<k1> <k2> ... : "value" keysym` into the macOS KeyBindings.dict path it
+// maps to and the leaf — inserted text or a selector sequence — that path
+// should be bound to.
+func parseEntry(text string, user map[string]rune) (path []string, val leaf, err error) {
+	// Split on the first colon only: a selector-sequence value (see
+	// parseValue) legitimately contains further colons of its own.
+	parts := strings.SplitN(text, ":", 2)
+	if len(parts) < 2 {
+		return nil, nil, fmt.Errorf("unexpected number of parts: %s", text)
+	}
+	path = strings.Fields(parts[0])
+	var bad []string
+	for i, p := range path {
+		k, err := keyFor(p, user)
 		if err != nil {
-			log.Fatalf("failed to unquote value %s: %v", sc.Text(), err)
-		}
-		known := true
-		for _, p := range path {
-			if strings.HasPrefix(p, "<") && strings.HasSuffix(p, ">") {
-				known = false
-				break
-			}
-		}
-		if !known {
+			bad = append(bad, fmt.Sprintf("%s (%v)", p, err))
 			continue
 		}
-		insert(mapping, val, path...)
+		path[i] = k
 	}
-	return mapping
+	if len(bad) != 0 {
+		return nil, nil, &unresolvedKeysymError{detail: "unresolved keysym(s): " + strings.Join(bad, ", ")}
+	}
+	val, err = parseValue(strings.FieldsFunc(parts[1], func(r rune) bool {
+		return r == ' ' || r == '\t'
+	})[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse value %s: %w", text, err)
+	}
+	return path, val, nil
+}
+
+// leaf is the value bound to a path in the key binding dict: either literal
+// text to insert, or a sequence of Cocoa selectors to invoke.
+type leaf interface {
+	// emit writes the leaf's KeyBindings.dict representation, without a
+	// trailing ";" or newline.
+	emit(w io.Writer) error
+}
+
+// insertText is a leaf that inserts a literal string.
+type insertText struct{ s string }
+
+func (v insertText) emit(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "(\"insertText:\", %s)", quote(v.s))
+	return err
+}
+
+// selectors is a leaf that invokes one or more NSResponder selectors in
+// sequence, e.g. {"moveToBeginningOfParagraph", "selectParagraph"} for
+// "moveToBeginningOfParagraph:selectParagraph:".
+type selectors struct{ names []string }
+
+func (v selectors) emit(w io.Writer) error {
+	if len(v.names) == 1 {
+		_, err := fmt.Fprint(w, quote(v.names[0]+":"))
+		return err
+	}
+	parts := make([]string, len(v.names))
+	for i, n := range v.names {
+		parts[i] = quote(n + ":")
+	}
+	_, err := fmt.Fprintf(w, "(%s)", strings.Join(parts, ", "))
+	return err
 }
 
+// parseValue interprets a quoted Compose value. A value of the form
+// "@selector:another:" binds the sequence to that chain of Cocoa selectors
+// instead of inserting literal text. A literal string that must itself
+// start with "@" is written with the prefix doubled, "@@...", which is
+// collapsed to a single literal "@" on insertion.
+func parseValue(quoted string) (leaf, error) {
+	s, err := strconv.Unquote(quoted)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasPrefix(s, "@@"):
+		return insertText{s: "@" + s[2:]}, nil
+	case strings.HasPrefix(s, "@"):
+		s = strings.TrimSuffix(s[1:], ":")
+		if s == "" {
+			return nil, fmt.Errorf("empty selector sequence in %s", quoted)
+		}
+		return selectors{names: strings.Split(s, ":")}, nil
+	default:
+		return insertText{s: s}, nil
+	}
+}
+
+// modifierPrefixes are the libX11 modifier names that may prefix a keysym
+// token, e.g. "Ctrl<apostrophe>".
+var modifierPrefixes = []string{"None", "Ctrl", "Shift", "Alt"}
+
+// keyFor resolves a single path token — a "<keysym>" or "<Uxxxx>" name, or a
+// modifier-prefixed keysym such as "Ctrl<apostrophe>" — to the literal
+// character it corresponds to.
+//
+// Modifier-prefixed keysyms are explicitly not honored: a KeyBindings.dict
+// path is a plain sequence of characters typed, with no way to express "this
+// keysym pressed with Ctrl/Shift/Alt held". Rather than guess at a lossy
+// mapping, such tokens are reported as unresolved so the sequence using them
+// is dropped, same as any other keysym xcompose doesn't know about.
 func keyFor(name string, user map[string]rune) (string, error) {
+	for _, mod := range modifierPrefixes {
+		if rest := strings.TrimPrefix(name, mod); rest != name && strings.HasPrefix(rest, "<") {
+			return "", fmt.Errorf("modifier prefix %s on %s is not representable in a KeyBindings.dict path", mod, rest)
+		}
+	}
 	if strings.HasPrefix(name, "<U") {
 		utf, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, "<U"), ">"), 16, 32)
 		if err != nil {
@@ -155,7 +487,197 @@ func keyFor(name string, user map[string]rune) (string, error) {
 	return "", fmt.Errorf("no value for %s", name)
 }
 
-func insert(dst map[string]interface{}, val string, path ...string) {
+// loadXCompose loads the XCompose file at path, recursively resolving
+// "include" directives per libX11's %L/%S/%H conventions, and returns the
+// key binding dict built from it. As in buildDict, lines with unresolved
+// keysyms are dropped and reported through diag rather than aborting the
+// load.
+func loadXCompose(path string, user map[string]rune, diag func(source, text, reason string)) (map[string]interface{}, error) {
+	return loadXComposeIncluding(path, user, diag, nil)
+}
+
+// loadXComposeIncluding is loadXCompose with the set of paths already being
+// loaded threaded through, so that an "include" cycle (directly or
+// indirectly including the file it started from) is reported as an error
+// instead of recursing until the stack overflows.
+func loadXComposeIncluding(path string, user map[string]rune, diag func(source, text, reason string), including map[string]bool) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = filepath.Clean(path)
+	}
+	if including[abs] {
+		return nil, fmt.Errorf("include cycle: %s includes itself", path)
+	}
+	including = copyIncluding(including, abs)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	mapping := make(map[string]interface{})
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "include"):
+			inc, err := parseInclude(line)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			included, err := loadXComposeIncluding(inc, user, diag, including)
+			if err != nil {
+				return nil, fmt.Errorf("include %q from %s: %w", inc, path, err)
+			}
+			mergeInto(mapping, included)
+		// An entry whose first event carries a modifier (e.g. "Ctrl <a>") is
+		// parsed too, so that parseEntry reports it as unresolved.
+		case strings.HasPrefix(line, "<") || strings.Contains(strings.SplitN(line, ":", 2)[0], "<"):
+			p, val, err := parseEntry(line, user)
+			if err != nil {
+				var uerr *unresolvedKeysymError
+				if errors.As(err, &uerr) {
+					if diag != nil {
+						diag(path, line, err.Error())
+					}
+					continue
+				}
+				return nil, fmt.Errorf("%s: %s: %w", path, line, err)
+			}
+			insert(mapping, val, p...)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// copyIncluding returns a copy of including with abs added, leaving the
+// original untouched so sibling "include" directives in the same file don't
+// see each other's inclusions as cycles.
+func copyIncluding(including map[string]bool, abs string) map[string]bool {
+	next := make(map[string]bool, len(including)+1)
+	for p := range including {
+		next[p] = true
+	}
+	next[abs] = true
+	return next
+}
+
+// mergeInto merges src into dst, recursing into matching subtrees so that an
+// entry in src overrides only the specific leaf it names rather than
+// replacing an entire sibling subtree.
+func mergeInto(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if sub, ok := v.(map[string]interface{}); ok {
+			dsub, ok := dst[k].(map[string]interface{})
+			if !ok {
+				dsub = make(map[string]interface{})
+				dst[k] = dsub
+			}
+			mergeInto(dsub, sub)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// parseInclude extracts and resolves the quoted path argument of an
+// "include" directive line.
+func parseInclude(line string) (string, error) {
+	i := strings.IndexByte(line, '"')
+	j := strings.LastIndexByte(line, '"')
+	if i < 0 || j <= i {
+		return "", fmt.Errorf("malformed include directive: %s", line)
+	}
+	raw, err := strconv.Unquote(line[i : j+1])
+	if err != nil {
+		return "", fmt.Errorf("malformed include directive: %s: %w", line, err)
+	}
+	return expandIncludePath(raw)
+}
+
+// expandIncludePath resolves the %L, %S and %H tokens libX11 recognizes in
+// "include" directive paths: %L the current locale's compose file, %S the
+// system compose directory, %H the user's home directory.
+func expandIncludePath(raw string) (string, error) {
+	home, homeErr := os.UserHomeDir()
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '%' || i+1 == len(raw) {
+			b.WriteByte(raw[i])
+			continue
+		}
+		switch raw[i+1] {
+		case 'H':
+			if homeErr != nil {
+				return "", homeErr
+			}
+			b.WriteString(home)
+			i++
+		case 'S':
+			b.WriteString(systemComposeDir)
+			i++
+		case 'L':
+			b.WriteString(localeComposeFile())
+			i++
+		default:
+			b.WriteByte(raw[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// localeComposeFile approximates libX11's %L resolution. The real
+// implementation consults the system compose.dir alias table to map the
+// current locale onto a compose file; lacking that table here, the locale
+// name itself is used as the subdirectory of systemComposeDir, which holds
+// for the common case of an unaliased locale name such as "en_US.UTF-8".
+func localeComposeFile() string {
+	loc := os.Getenv("LC_ALL")
+	if loc == "" {
+		loc = os.Getenv("LC_CTYPE")
+	}
+	if loc == "" {
+		loc = os.Getenv("LANG")
+	}
+	if loc == "" {
+		loc = "C"
+	}
+	return filepath.Join(systemComposeDir, loc, "Compose")
+}
+
+// diagnostic is a machine-readable record of a dropped Compose line,
+// emitted as JSON so users and tooling can see which lines were dropped and
+// why.
+type diagnostic struct {
+	Source string `json:"source,omitempty"` // file the line came from; empty for lines read by buildDict (the -f file or the embedded default).
+	Text   string `json:"text"`
+	Reason string `json:"reason"`
+}
+
+// recordDiagnostic returns a diag callback, as taken by buildDict and
+// loadXCompose, that appends to diags.
+func recordDiagnostic(diags *[]diagnostic) func(source, text, reason string) {
+	return func(source, text, reason string) {
+		*diags = append(*diags, diagnostic{Source: source, Text: text, Reason: reason})
+	}
+}
+
+// reportDiagnostics writes diags to stderr as JSON lines, one per dropped
+// Compose entry.
+func reportDiagnostics(diags []diagnostic) {
+	enc := json.NewEncoder(os.Stderr)
+	for _, d := range diags {
+		enc.Encode(d)
+	}
+}
+
+func insert(dst map[string]interface{}, val interface{}, path ...string) {
 	if len(path) == 0 {
 		return
 	}
@@ -194,8 +716,12 @@ func format(w io.Writer, dict map[string]interface{}, depth int) error {
 			return err
 		}
 		switch val := dict[k].(type) {
-		case string:
-			_, err = fmt.Fprintf(w, "(\"insertText:\", %s);\n", quote(val))
+		case leaf:
+			err = val.emit(w)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(w, ";")
 			if err != nil {
 				return err
 			}
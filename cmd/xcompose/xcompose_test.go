@@ -27,10 +27,10 @@ var testCases = []struct {
 	{
 		name: "one by one",
 		seq: []sequence{
-			{path: []string{"1"}, val: "text"},
+			{path: []string{"1"}, val: insertText{s: "text"}},
 		},
 		wantMapping: map[string]interface{}{
-			"1": "text",
+			"1": insertText{s: "text"},
 		},
 		wantFormatted: `{
 	"1" = ("insertText:", "text");
@@ -40,11 +40,11 @@ var testCases = []struct {
 	{
 		name: "one by two",
 		seq: []sequence{
-			{path: []string{"1", "2"}, val: "text"},
+			{path: []string{"1", "2"}, val: insertText{s: "text"}},
 		},
 		wantMapping: map[string]interface{}{
 			"1": map[string]interface{}{
-				"2": "text",
+				"2": insertText{s: "text"},
 			},
 		},
 		wantFormatted: `{
@@ -57,12 +57,12 @@ var testCases = []struct {
 	{
 		name: "two by one",
 		seq: []sequence{
-			{path: []string{"1"}, val: "text1"},
-			{path: []string{"2"}, val: "text2"},
+			{path: []string{"1"}, val: insertText{s: "text1"}},
+			{path: []string{"2"}, val: insertText{s: "text2"}},
 		},
 		wantMapping: map[string]interface{}{
-			"1": "text1",
-			"2": "text2",
+			"1": insertText{s: "text1"},
+			"2": insertText{s: "text2"},
 		},
 		wantFormatted: `{
 	"1" = ("insertText:", "text1");
@@ -73,13 +73,13 @@ var testCases = []struct {
 	{
 		name: "two by two",
 		seq: []sequence{
-			{path: []string{"1", "2"}, val: "text1"},
-			{path: []string{"1", "3"}, val: "text2"},
+			{path: []string{"1", "2"}, val: insertText{s: "text1"}},
+			{path: []string{"1", "3"}, val: insertText{s: "text2"}},
 		},
 		wantMapping: map[string]interface{}{
 			"1": map[string]interface{}{
-				"2": "text1",
-				"3": "text2",
+				"2": insertText{s: "text1"},
+				"3": insertText{s: "text2"},
 			},
 		},
 		wantFormatted: `{
@@ -88,13 +88,59 @@ var testCases = []struct {
 		"3" = ("insertText:", "text2");
 	};
 }
+`,
+	},
+	{
+		name: "single selector",
+		seq: []sequence{
+			{path: []string{"1"}, val: selectors{names: []string{"deleteBackward"}}},
+		},
+		wantMapping: map[string]interface{}{
+			"1": selectors{names: []string{"deleteBackward"}},
+		},
+		wantFormatted: `{
+	"1" = "deleteBackward:";
+}
+`,
+	},
+	{
+		name: "multiple selectors",
+		seq: []sequence{
+			{path: []string{"1"}, val: selectors{names: []string{"moveToBeginningOfParagraph", "selectParagraph"}}},
+		},
+		wantMapping: map[string]interface{}{
+			"1": selectors{names: []string{"moveToBeginningOfParagraph", "selectParagraph"}},
+		},
+		wantFormatted: `{
+	"1" = ("moveToBeginningOfParagraph:", "selectParagraph:");
+}
+`,
+	},
+	{
+		name: "mixed tree",
+		seq: []sequence{
+			{path: []string{"1", "2"}, val: insertText{s: "text"}},
+			{path: []string{"1", "3"}, val: selectors{names: []string{"deleteBackward"}}},
+		},
+		wantMapping: map[string]interface{}{
+			"1": map[string]interface{}{
+				"2": insertText{s: "text"},
+				"3": selectors{names: []string{"deleteBackward"}},
+			},
+		},
+		wantFormatted: `{
+	"1" = {
+		"2" = ("insertText:", "text");
+		"3" = "deleteBackward:";
+	};
+}
 `,
 	},
 }
 
 type sequence struct {
 	path []string
-	val  string
+	val  leaf
 }
 
 func Test(t *testing.T) {